@@ -0,0 +1,314 @@
+// Package cluster implements agglomerative hierarchical clustering over a
+// distance matrix and renders the resulting dendrogram as a Newick tree or
+// as flat clusters cut at a distance threshold. The in-memory path supports
+// single-, complete-, and average-linkage; the large-N, constant-memory
+// path implements Sibson's SLINK algorithm for single-linkage.
+package cluster
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Linkage selects the distance-update rule used when two clusters are
+// merged during in-memory agglomerative clustering.
+type Linkage int
+
+const (
+	Single Linkage = iota
+	Complete
+	Average
+)
+
+// ParseLinkage maps a -linkage flag value to a Linkage.
+func ParseLinkage(name string) (Linkage, error) {
+	switch name {
+	case "single":
+		return Single, nil
+	case "complete":
+		return Complete, nil
+	case "average":
+		return Average, nil
+	default:
+		return 0, fmt.Errorf("unknown linkage %q (want single, complete, or average)", name)
+	}
+}
+
+// Node is one node of a hierarchical clustering dendrogram. Leaves carry a
+// Label and no children; internal nodes carry Left and Right and the
+// distance at which they were merged.
+type Node struct {
+	Label       string
+	Left, Right *Node
+	Height      float64
+}
+
+// Newick renders the dendrogram rooted at n in Newick format, with branch
+// lengths equal to the difference between a node's merge height and its
+// parent's.
+func (n *Node) Newick() string {
+	var b strings.Builder
+	n.writeSubtree(&b)
+	b.WriteByte(';')
+	return b.String()
+}
+
+func (n *Node) writeSubtree(b *strings.Builder) {
+	if n.Left == nil {
+		b.WriteString(n.Label)
+		return
+	}
+	b.WriteByte('(')
+	n.Left.writeSubtree(b)
+	fmt.Fprintf(b, ":%s", formatBranchLength(n.Height-n.Left.Height))
+	b.WriteByte(',')
+	n.Right.writeSubtree(b)
+	fmt.Fprintf(b, ":%s", formatBranchLength(n.Height-n.Right.Height))
+	b.WriteByte(')')
+}
+
+func formatBranchLength(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// CutTree cuts the dendrogram rooted at root at the given distance
+// threshold: every subtree whose root was merged at a height <= threshold
+// becomes one flat cluster. It returns each leaf label's cluster id.
+func CutTree(root *Node, threshold float64) map[string]int {
+	assignments := make(map[string]int)
+	nextID := 0
+
+	var collectLeaves func(n *Node, id int)
+	collectLeaves = func(n *Node, id int) {
+		if n.Left == nil {
+			assignments[n.Label] = id
+			return
+		}
+		collectLeaves(n.Left, id)
+		collectLeaves(n.Right, id)
+	}
+
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n.Left == nil || n.Height <= threshold {
+			collectLeaves(n, nextID)
+			nextID++
+			return
+		}
+		walk(n.Left)
+		walk(n.Right)
+	}
+	walk(root)
+
+	return assignments
+}
+
+// AgglomerativeInMemory clusters labels using the nearest-neighbor-chain
+// algorithm (Murtagh 1983): instead of rescanning every active pair to find
+// the next merge (O(n) per merge, O(n^2) per merge when repeated per pair,
+// O(n^3) overall), it follows a chain of mutual-nearest-neighbor candidates
+// from an arbitrary active cluster until the chain closes on itself, which
+// is guaranteed to be the next pair to merge for any Lance-Williams update
+// rule (single, complete, and average linkage all qualify). That brings the
+// whole clustering down to O(n^2) time, the same order as the distance
+// matrix it holds in memory -- which is still why this is the in-memory
+// (small-N) path rather than the large-N disk path.
+func AgglomerativeInMemory(labels []string, dist func(a, b string) float64, linkage Linkage) *Node {
+	n := len(labels)
+	if n == 0 {
+		return nil
+	}
+
+	nodes := make([]*Node, n)
+	size := make([]int, n) // 0 marks a cluster as merged away
+	dmat := make([][]float64, n)
+	for i, label := range labels {
+		nodes[i] = &Node{Label: label}
+		size[i] = 1
+		dmat[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			d := dist(labels[i], labels[j])
+			dmat[i][j] = d
+			dmat[j][i] = d
+		}
+	}
+
+	chain := make([]int, 0, n)
+	for remaining := n; remaining > 1; remaining-- {
+		if len(chain) == 0 {
+			for i := 0; i < n; i++ {
+				if size[i] > 0 {
+					chain = append(chain, i)
+					break
+				}
+			}
+		}
+
+		var x, y int
+		for {
+			x = chain[len(chain)-1]
+
+			current := math.Inf(1)
+			if len(chain) > 1 {
+				y = chain[len(chain)-2]
+				current = dmat[x][y]
+			}
+
+			for i := 0; i < n; i++ {
+				if size[i] == 0 || i == x {
+					continue
+				}
+				if dmat[x][i] < current {
+					current = dmat[x][i]
+					y = i
+				}
+			}
+
+			if len(chain) > 1 && y == chain[len(chain)-2] {
+				break
+			}
+			chain = append(chain, y)
+		}
+
+		height := dmat[x][y]
+		chain = chain[:len(chain)-2]
+		if x > y {
+			x, y = y, x
+		}
+
+		nx, ny := size[x], size[y]
+		merged := &Node{Left: nodes[x], Right: nodes[y], Height: height}
+
+		for i := 0; i < n; i++ {
+			if size[i] == 0 || i == y {
+				continue
+			}
+			var d float64
+			switch linkage {
+			case Complete:
+				d = math.Max(dmat[x][i], dmat[y][i])
+			case Average:
+				d = (float64(nx)*dmat[x][i] + float64(ny)*dmat[y][i]) / float64(nx+ny)
+			default: // Single
+				d = math.Min(dmat[x][i], dmat[y][i])
+			}
+			dmat[y][i], dmat[i][y] = d, d
+		}
+
+		size[x] = 0
+		size[y] = nx + ny
+		nodes[y] = merged
+
+		// Deliberately leave the rest of chain untouched: whatever sits below
+		// the popped pair is still a valid resumption point, since y (now
+		// carrying the merged cluster) stays active and is considered like
+		// any other candidate the next time that element looks for its
+		// nearest neighbor. Overwriting it here used to clobber that
+		// resumption point, corrupting later merges in the same chain walk.
+	}
+
+	for i := 0; i < n; i++ {
+		if size[i] > 0 {
+			return nodes[i]
+		}
+	}
+	return nil // unreachable: the loop above always leaves exactly one active cluster
+}
+
+// SLINK computes a single-linkage dendrogram in O(n^2) time and O(n) memory
+// (Sibson 1973). rowAt(i) must return point i's distances to every point
+// labels[0:i] plus the rest of the row (so callers backed by a single
+// sequential scan, such as a merged external-sort stream, stay in sync);
+// only entries with index < i are read.
+func SLINK(labels []string, rowAt func(i int) ([]float64, error)) (*Node, error) {
+	n := len(labels)
+	if n == 0 {
+		return nil, fmt.Errorf("cannot cluster zero labels")
+	}
+	if n == 1 {
+		return &Node{Label: labels[0]}, nil
+	}
+
+	pi := make([]int, n)
+	lambda := make([]float64, n)
+	m := make([]float64, n)
+
+	pi[0] = 0
+	lambda[0] = math.Inf(1)
+
+	for i := 1; i < n; i++ {
+		row, err := rowAt(i)
+		if err != nil {
+			return nil, err
+		}
+
+		pi[i] = i
+		lambda[i] = math.Inf(1)
+
+		for j := 0; j < i; j++ {
+			m[j] = row[j]
+		}
+		for j := 0; j < i; j++ {
+			if lambda[j] >= m[j] {
+				m[pi[j]] = math.Min(m[pi[j]], lambda[j])
+				lambda[j] = m[j]
+				pi[j] = i
+			} else {
+				m[pi[j]] = math.Min(m[pi[j]], m[j])
+			}
+		}
+		for j := 0; j < i; j++ {
+			if lambda[j] >= lambda[pi[j]] {
+				pi[j] = i
+			}
+		}
+	}
+
+	return pointerRepToTree(labels, pi, lambda), nil
+}
+
+// pointerRepToTree converts SLINK's pointer representation (pi, lambda) into
+// a binary dendrogram by replaying merges in ascending order of lambda,
+// union-find style.
+func pointerRepToTree(labels []string, pi []int, lambda []float64) *Node {
+	n := len(labels)
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return lambda[order[a]] < lambda[order[b]] })
+
+	rep := make([]int, n)
+	node := make([]*Node, n)
+	for i, label := range labels {
+		rep[i] = i
+		node[i] = &Node{Label: label}
+	}
+
+	var find func(int) int
+	find = func(x int) int {
+		for rep[x] != x {
+			x = rep[x]
+		}
+		return x
+	}
+
+	for _, i := range order {
+		if math.IsInf(lambda[i], 1) {
+			continue // root sentinel; nothing to merge
+		}
+		a, b := find(i), find(pi[i])
+		if a == b {
+			continue
+		}
+		node[b] = &Node{Left: node[a], Right: node[b], Height: lambda[i]}
+		rep[a] = b
+	}
+
+	return node[find(0)]
+}
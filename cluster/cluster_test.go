@@ -0,0 +1,150 @@
+package cluster
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// collectLeaves walks the dendrogram rooted at n and returns the set of leaf
+// labels reachable from it.
+func collectLeaves(n *Node) map[string]bool {
+	leaves := make(map[string]bool)
+	var walk func(*Node)
+	walk = func(n *Node) {
+		if n.Left == nil {
+			leaves[n.Label] = true
+			return
+		}
+		walk(n.Left)
+		walk(n.Right)
+	}
+	walk(n)
+	return leaves
+}
+
+// randomDistMatrix builds a symmetric, zero-diagonal distance matrix from a
+// fixed seed so the test is deterministic.
+func randomDistMatrix(n int, seed int64) [][]float64 {
+	rng := rand.New(rand.NewSource(seed))
+	d := make([][]float64, n)
+	for i := range d {
+		d[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			v := rng.Float64() * 10
+			d[i][j], d[j][i] = v, v
+		}
+	}
+	return d
+}
+
+// TestAgglomerativeInMemoryVisitsEveryLeaf is a regression test: the
+// nearest-neighbor-chain walk used to overwrite its own resumption point
+// after every merge, corrupting later merges in the same chain walk and
+// silently dropping most labels from the dendrogram for complete and
+// average linkage (single linkage's chains happened to stay short enough
+// on small inputs to avoid triggering it).
+func TestAgglomerativeInMemoryVisitsEveryLeaf(t *testing.T) {
+	const n = 60
+	dmat := randomDistMatrix(n, 42)
+	labels := make([]string, n)
+	for i := range labels {
+		labels[i] = string(rune('a'+i%26)) + string(rune('0'+i/26))
+	}
+	dist := func(a, b string) float64 {
+		ia, ib := -1, -1
+		for i, l := range labels {
+			if l == a {
+				ia = i
+			}
+			if l == b {
+				ib = i
+			}
+		}
+		return dmat[ia][ib]
+	}
+
+	for _, linkage := range []Linkage{Single, Complete, Average} {
+		root := AgglomerativeInMemory(labels, dist, linkage)
+		leaves := collectLeaves(root)
+		if len(leaves) != n {
+			t.Errorf("linkage %v: got %d leaves, want %d", linkage, len(leaves), n)
+		}
+		for _, l := range labels {
+			if !leaves[l] {
+				t.Errorf("linkage %v: label %q missing from dendrogram", linkage, l)
+			}
+		}
+	}
+}
+
+// TestAgglomerativeInMemorySingleMatchesSLINK cross-checks the
+// nearest-neighbor-chain implementation's single-linkage merge heights
+// against SLINK, an independently derived algorithm for the same linkage.
+func TestAgglomerativeInMemorySingleMatchesSLINK(t *testing.T) {
+	const n = 40
+	dmat := randomDistMatrix(n, 7)
+	labels := make([]string, n)
+	for i := range labels {
+		labels[i] = string(rune('a'+i%26)) + string(rune('0'+i/26))
+	}
+	dist := func(a, b string) float64 {
+		ia, ib := -1, -1
+		for i, l := range labels {
+			if l == a {
+				ia = i
+			}
+			if l == b {
+				ib = i
+			}
+		}
+		return dmat[ia][ib]
+	}
+	rowAt := func(i int) ([]float64, error) {
+		return dmat[i], nil
+	}
+
+	chainHeights := mergeHeights(AgglomerativeInMemory(labels, dist, Single))
+
+	slinkRoot, err := SLINK(labels, rowAt)
+	if err != nil {
+		t.Fatalf("SLINK: %v", err)
+	}
+	slinkHeights := mergeHeights(slinkRoot)
+
+	if len(chainHeights) != len(slinkHeights) {
+		t.Fatalf("got %d merge heights, want %d", len(chainHeights), len(slinkHeights))
+	}
+	for i := range chainHeights {
+		if math.Abs(chainHeights[i]-slinkHeights[i]) > 1e-9 {
+			t.Errorf("merge height %d: got %v, want %v", i, chainHeights[i], slinkHeights[i])
+		}
+	}
+}
+
+// mergeHeights returns every internal node's Height in ascending order.
+func mergeHeights(n *Node) []float64 {
+	var heights []float64
+	var walk func(*Node)
+	walk = func(n *Node) {
+		if n.Left == nil {
+			return
+		}
+		heights = append(heights, n.Height)
+		walk(n.Left)
+		walk(n.Right)
+	}
+	walk(n)
+	sortFloats(heights)
+	return heights
+}
+
+func sortFloats(f []float64) {
+	for i := 1; i < len(f); i++ {
+		for j := i; j > 0 && f[j-1] > f[j]; j-- {
+			f[j-1], f[j] = f[j], f[j-1]
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Command distwiz converts a sparse (long-format) distance matrix into a
+// gzip-compressed square matrix, or runs clustering on it directly. The
+// conversion logic itself lives in the distwiz package so it can also be
+// imported by other Go code; this file only wires flags to distwiz.Config.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/vmikk/distwiz"
+	"github.com/vmikk/distwiz/vfs"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		runVerify(os.Args[2:])
+		return
+	}
+
+	// Parse command-line arguments
+	inputPath := flag.String("input", "", "Path to the input file containing the sparse distance matrix.")
+	outputPath := flag.String("output", "", "Path to the output gzip-compressed file.")
+	compressLevel := flag.Int("compresslevel", 4, "GZIP compression level (1-9). Default is 4.")
+	memLimit := flag.Int("memlimit", distwiz.DefaultMemLimit, "Memory budget in bytes for the external sort used on large inputs.")
+	threads := flag.Int("threads", 1, "Number of goroutines used to render rows and compress output blocks. 1 runs the serial path.")
+	format := flag.String("format", "tsv", "Output format: tsv (gzip-compressed TSV, default) or recordio (chunked, indexed binary; see the matrixio package).")
+	clusterMode := flag.Bool("cluster", false, "Run agglomerative clustering on the distances instead of writing the square matrix.")
+	linkage := flag.String("linkage", "single", "Linkage for -cluster: single, complete, or average. Only single is supported above the large-N threshold.")
+	treePath := flag.String("tree", "", "With -cluster, write the dendrogram in Newick format to this path.")
+	cutThreshold := flag.Float64("cut", -1, "With -cluster, cut the dendrogram at this distance threshold into flat clusters.")
+	clustersPath := flag.String("clusters", "", "With -cluster and -cut, write flat cluster assignments (label, cluster ID) to this path.")
+	flag.Parse()
+
+	// Validate arguments. -cluster writes its output to -tree/-clusters
+	// instead (validated in runCluster), so -output isn't required there.
+	if *inputPath == "" {
+		log.Fatal("An input path is required.")
+	}
+	if !*clusterMode && *outputPath == "" {
+		log.Fatal("An output path is required unless -cluster is set.")
+	}
+
+	cfg := distwiz.Config{
+		InputPath:     *inputPath,
+		OutputPath:    *outputPath,
+		CompressLevel: *compressLevel,
+		MemLimit:      *memLimit,
+		Threads:       *threads,
+		Format:        *format,
+		Cluster:       *clusterMode,
+		Linkage:       *linkage,
+		TreePath:      *treePath,
+		CutThreshold:  *cutThreshold,
+		ClustersPath:  *clustersPath,
+	}
+
+	if err := distwiz.Run(cfg); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+// runVerify handles the "distwiz verify <path>" subcommand: it recomputes
+// the CRC32C and row/column counts of a gzip-TSV output file and compares
+// them against its trailing integrity footer.
+func runVerify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: distwiz verify <path>")
+	}
+
+	if err := distwiz.Verify(vfs.OS{}, fs.Arg(0)); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	fmt.Println("OK")
+}
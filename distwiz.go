@@ -1,53 +1,92 @@
-package main
+// Package distwiz converts a sparse (long-format) distance matrix into a
+// square matrix, or clusters it directly. See Run and Config for the
+// library entry point, and cmd/distwiz for the CLI built on top of it.
+package distwiz
 
 import (
 	"bufio"
+	"bytes"
 	"compress/gzip"
-	"flag"
 	"fmt"
-	"log"
-	"os"
+	"io"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/vmikk/distwiz/cluster"
+	"github.com/vmikk/distwiz/extsort"
+	"github.com/vmikk/distwiz/matrixio"
+	"github.com/vmikk/distwiz/pargzip"
+	"github.com/vmikk/distwiz/vfs"
 )
 
 // Threshold for deciding between processing methods (in-memory or disk-based)
 const LargeNThreshold = 10000
 
-func main() {
-	// Parse command-line arguments
-	inputPath := flag.String("input", "", "Path to the input file containing the sparse distance matrix.")
-	outputPath := flag.String("output", "", "Path to the output gzip-compressed file.")
-	compressLevel := flag.Int("compresslevel", 4, "GZIP compression level (1-9). Default is 4.")
-	flag.Parse()
+// Default memory budget for the external sort used by the large-N disk path,
+// in bytes. Overridable via -memlimit.
+const DefaultMemLimit = 256 << 20 // 256 MiB
+
+// Config holds the parameters for one conversion (or clustering) run. It
+// mirrors the command-line flags so Run can be driven either from main or
+// by downstream Go code embedding distwiz as a library. Fs defaults to the
+// local filesystem when nil.
+type Config struct {
+	Fs vfs.Fs
+
+	InputPath     string
+	OutputPath    string
+	CompressLevel int
+	MemLimit      int
+	Threads       int
+	Format        string // "tsv" (default) or "recordio"
+
+	Cluster      bool
+	Linkage      string
+	TreePath     string
+	CutThreshold float64
+	ClustersPath string
+}
 
-	// Validate arguments
-	if *inputPath == "" || *outputPath == "" {
-		log.Fatal("Both input and output paths are required.")
+// Run executes one conversion or clustering run according to cfg. It is the
+// library entry point cmd/distwiz wires up to command-line flags, exposed
+// so distwiz can be invoked as a library without shelling out.
+func Run(cfg Config) error {
+	fsys := cfg.Fs
+	if fsys == nil {
+		fsys = vfs.OS{}
 	}
 
-	labels, err := scanForLabels(*inputPath)
+	labels, err := scanForLabels(fsys, cfg.InputPath)
 	if err != nil {
-		log.Fatalf("Error scanning for labels: %v", err)
+		return fmt.Errorf("error scanning for labels: %w", err)
+	}
+
+	if cfg.Cluster {
+		return runCluster(fsys, cfg.InputPath, labels, cfg.Linkage, cfg.MemLimit, cfg.TreePath, cfg.CutThreshold, cfg.ClustersPath)
 	}
 
 	// Choose processing method based on the number of labels
-	if len(labels) > LargeNThreshold {
-		if err := writeSquareMatrix(*outputPath, *inputPath, labels, *compressLevel); err != nil {
-			log.Fatalf("Error writing square matrix: %v", err)
-		}
-	} else {
-		if err := writeSquareMatrixInMemory(*outputPath, *inputPath, labels, *compressLevel); err != nil {
-			log.Fatalf("Error writing square matrix using in-memory method: %v", err)
+	large := len(labels) > LargeNThreshold
+
+	if cfg.Format == "recordio" {
+		if large {
+			return writeRecordIOMatrixLarge(fsys, cfg.OutputPath, cfg.InputPath, labels, cfg.CompressLevel, cfg.MemLimit)
 		}
+		return writeRecordIOMatrix(fsys, cfg.OutputPath, cfg.InputPath, labels, cfg.CompressLevel)
+	}
+
+	if large {
+		return writeSquareMatrix(fsys, cfg.OutputPath, cfg.InputPath, labels, cfg.CompressLevel, cfg.MemLimit)
 	}
+	return writeSquareMatrixInMemory(fsys, cfg.OutputPath, cfg.InputPath, labels, cfg.CompressLevel, cfg.Threads)
 }
 
 // Scan the input file for all unique labels
-func scanForLabels(inputPath string) ([]string, error) {
+func scanForLabels(fsys vfs.Fs, inputPath string) ([]string, error) {
 	labelsSet := make(map[string]struct{})
-	file, err := os.Open(inputPath)
+	file, err := fsys.Open(inputPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open input file: %w", err)
 	}
@@ -74,9 +113,61 @@ func scanForLabels(inputPath string) ([]string, error) {
 	return labels, nil
 }
 
-// Write the square matrix by processing each label
-func writeSquareMatrix(outputPath, inputPath string, labels []string, compressLevel int) error {
-	file, err := os.Create(outputPath)
+// matrixRecord is one (row, col) -> distance entry fed through the external
+// sort. Both (a,b,d) and (b,a,d) are emitted for every input triple so the
+// merged stream can be walked once per output row.
+type matrixRecord struct {
+	Row, Col string
+	Dist     float64
+}
+
+// matrixCodec encodes matrixRecords as tab-separated lines, keeping the
+// external-sort run files in the same plain-text style as the rest of the
+// tool's I/O.
+type matrixCodec struct{}
+
+func (matrixCodec) Encode(w *bufio.Writer, rec extsort.Record) error {
+	r := rec.(matrixRecord)
+	_, err := fmt.Fprintf(w, "%s\t%s\t%s\n", r.Row, r.Col, strconv.FormatFloat(r.Dist, 'f', -1, 64))
+	return err
+}
+
+func (matrixCodec) Decode(r *bufio.Reader) (extsort.Record, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && line == "" {
+		return nil, err
+	}
+	parts := strings.Split(strings.TrimSuffix(line, "\n"), "\t")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed run record: %q", line)
+	}
+	dist, perr := strconv.ParseFloat(parts[2], 64)
+	if perr != nil {
+		return nil, fmt.Errorf("malformed run record distance: %w", perr)
+	}
+	return matrixRecord{Row: parts[0], Col: parts[1], Dist: dist}, nil
+}
+
+func (matrixCodec) Size(rec extsort.Record) int {
+	r := rec.(matrixRecord)
+	return len(r.Row) + len(r.Col) + 24 // rough estimate including Go overhead
+}
+
+func matrixRecordLess(a, b extsort.Record) bool {
+	ra, rb := a.(matrixRecord), b.(matrixRecord)
+	if ra.Row != rb.Row {
+		return ra.Row < rb.Row
+	}
+	return ra.Col < rb.Col
+}
+
+// Write the square matrix in a single streaming pass backed by an external
+// (on-disk) sort: every input triple (a,b,d) is expanded into (a,b,d) and
+// (b,a,d) records, sorted in bounded-memory runs, and k-way merged so each
+// output row is produced by one linear scan of the merged stream, keeping
+// peak memory bounded by memLimit regardless of the number of labels.
+func writeSquareMatrix(fsys vfs.Fs, outputPath, inputPath string, labels []string, compressLevel, memLimit int) error {
+	file, err := fsys.Create(outputPath)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
 	}
@@ -86,117 +177,465 @@ func writeSquareMatrix(outputPath, inputPath string, labels []string, compressLe
 	if err != nil {
 		return fmt.Errorf("failed to create gzip writer: %w", err)
 	}
-	defer gz.Close()
 
-	writer := bufio.NewWriter(gz)
-	defer writer.Flush()
+	crcW := newCRCWriter(gz)
+	writer := bufio.NewWriter(crcW)
 
 	// Write header
 	if _, err := writer.WriteString(strings.Join(labels, "\t") + "\n"); err != nil {
 		return fmt.Errorf("failed to write to output file: %w", err)
 	}
 
-	for _, label1 := range labels {
-		if err := writeRow(writer, inputPath, label1, labels); err != nil {
-			return err // Error already wrapped
-		}
+	merger, err := sortMatrixRecords(fsys, inputPath, memLimit)
+	if err != nil {
+		return fmt.Errorf("failed to external-sort distances: %w", err)
 	}
+	defer merger.Close()
 
-	return nil
+	if err := writeRowsFromMerger(writer, merger, labels); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush output: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finalize gzip stream: %w", err)
+	}
+
+	return writeFooterMember(file, len(labels), len(labels), crcW.crc)
 }
 
-// Write a single row of the square matrix for a specific label
-// (without loading the entire file in memory)
-func writeRow(writer *bufio.Writer, inputPath, label1 string, labels []string) error {
-	distances := make(map[string]float64)
-	file, err := os.Open(inputPath)
+// sortMatrixRecords streams inputPath once, expanding each (a,b,d) triple
+// into (a,b,d) and (b,a,d) records, and returns a Merger yielding them in
+// (row, col) order.
+func sortMatrixRecords(fsys vfs.Fs, inputPath string, memLimit int) (*extsort.Merger, error) {
+	file, err := fsys.Open(inputPath)
 	if err != nil {
-		return fmt.Errorf("failed to open input file for reading distances: %w", err)
+		return nil, fmt.Errorf("failed to open input file: %w", err)
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		parts := strings.Fields(scanner.Text())
-		if len(parts) != 3 {
-			continue // Skip invalid lines
-		}
-		if parts[0] == label1 || parts[1] == label1 {
+	sorter := extsort.New(fsys, memLimit, matrixRecordLess, matrixCodec{})
+	records := make(chan extsort.Record)
+	scanErrCh := make(chan error, 1)
+
+	go func() {
+		defer close(records)
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			parts := strings.Fields(scanner.Text())
+			if len(parts) != 3 {
+				continue // Skip invalid lines
+			}
 			dist, err := strconv.ParseFloat(parts[2], 64)
 			if err != nil {
 				continue // Skip lines with invalid distances
 			}
-			if parts[0] == label1 {
-				distances[parts[1]] = dist
-			} else {
-				distances[parts[0]] = dist
-			}
+			records <- matrixRecord{Row: parts[0], Col: parts[1], Dist: dist}
+			records <- matrixRecord{Row: parts[1], Col: parts[0], Dist: dist}
 		}
+		scanErrCh <- scanner.Err()
+	}()
+
+	merger, err := sorter.Sort(records)
+	if err != nil {
+		return nil, err
 	}
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading distances for label %s: %w", label1, err)
+	if err := <-scanErrCh; err != nil {
+		merger.Close()
+		return nil, fmt.Errorf("error reading file: %w", err)
 	}
+	return merger, nil
+}
 
-	var row []string
-	for _, label2 := range labels {
-		if label1 == label2 {
-			row = append(row, "0.0")
-		} else if dist, found := distances[label2]; found {
-			row = append(row, fmt.Sprintf("%.1f", dist))
-		} else {
-			row = append(row, "1.0") // Default distance
+// consumeRow drains every record for label1 out of merger's (row, col)-sorted
+// stream into a Col->Dist map and returns the first record belonging to the
+// next row (if any). Sorting guarantees every record for label1 is
+// contiguous, so this always leaves the cursor aligned on a row boundary --
+// unlike matching records to labels one at a time, it can't be thrown out of
+// sync by a self-referential (a, a, d) triple or a duplicate pair, which
+// would otherwise leave an unconsumed record that desyncs every row after it.
+func consumeRow(merger *extsort.Merger, rec extsort.Record, ok bool, label1 string) (map[string]float64, extsort.Record, bool, error) {
+	rowVals := make(map[string]float64)
+	for ok {
+		cur := rec.(matrixRecord)
+		if cur.Row != label1 {
+			break
+		}
+		rowVals[cur.Col] = cur.Dist
+
+		var err error
+		rec, ok, err = merger.Next()
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("error reading sorted distances: %w", err)
 		}
 	}
-	if _, err := writer.WriteString(strings.Join(row, "\t") + "\n"); err != nil {
-		return fmt.Errorf("failed to write row for label %s: %w", label1, err)
+	return rowVals, rec, ok, nil
+}
+
+// writeRowsFromMerger walks labels against the (row, col)-sorted merged
+// stream, writing 1.0 for any (row, col) gap and 0.0 on the diagonal.
+func writeRowsFromMerger(writer *bufio.Writer, merger *extsort.Merger, labels []string) error {
+	rec, ok, err := merger.Next()
+	if err != nil {
+		return fmt.Errorf("error reading sorted distances: %w", err)
+	}
+
+	for _, label1 := range labels {
+		var rowVals map[string]float64
+		rowVals, rec, ok, err = consumeRow(merger, rec, ok, label1)
+		if err != nil {
+			return err
+		}
+
+		row := renderRowInMemory(label1, labels, map[string]map[string]float64{label1: rowVals})
+		if _, err := writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write row for label %s: %w", label1, err)
+		}
 	}
 
 	return nil
 }
 
-// Write the square matrix using in-memory data for small number of labels
-func writeSquareMatrixInMemory(outputPath, inputPath string, labels []string, compressLevel int) error {
+// Write the square matrix using in-memory data for small number of labels.
+// With threads <= 1 this runs the original serial path unchanged; with
+// threads > 1 it fans row rendering out across a worker pool and feeds a
+// block-parallel gzip writer instead.
+func writeSquareMatrixInMemory(fsys vfs.Fs, outputPath, inputPath string, labels []string, compressLevel, threads int) error {
 	// Open and read the entire input file into memory
-	data, err := readInputFileIntoMemory(inputPath)
+	data, err := readInputFileIntoMemory(fsys, inputPath)
 	if err != nil {
 		return err
 	}
 
 	// Proceed with file and gzip creation
-	file, err := os.Create(outputPath)
+	file, err := fsys.Create(outputPath)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
 	}
 	defer file.Close()
 
-	gz, err := gzip.NewWriterLevel(file, compressLevel)
-	if err != nil {
-		return fmt.Errorf("failed to create gzip writer: %w", err)
+	if threads <= 1 {
+		gz, err := gzip.NewWriterLevel(file, compressLevel)
+		if err != nil {
+			return fmt.Errorf("failed to create gzip writer: %w", err)
+		}
+
+		crcW := newCRCWriter(gz)
+		writer := bufio.NewWriter(crcW)
+
+		// Write header
+		if _, err := writer.WriteString(strings.Join(labels, "\t") + "\n"); err != nil {
+			return fmt.Errorf("failed to write to output file: %w", err)
+		}
+
+		// Process each label using the in-memory data
+		for _, label1 := range labels {
+			if err := writeRowInMemory(writer, label1, labels, data); err != nil {
+				return err // Error already wrapped
+			}
+		}
+
+		if err := writer.Flush(); err != nil {
+			return fmt.Errorf("failed to flush output: %w", err)
+		}
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to finalize gzip stream: %w", err)
+		}
+		return writeFooterMember(file, len(labels), len(labels), crcW.crc)
 	}
-	defer gz.Close()
 
-	writer := bufio.NewWriter(gz)
-	defer writer.Flush()
+	gz := pargzip.NewWriter(file, compressLevel, pargzip.DefaultBlockSize, threads)
+	crcW := newCRCWriter(gz)
 
 	// Write header
-	if _, err := writer.WriteString(strings.Join(labels, "\t") + "\n"); err != nil {
+	if _, err := crcW.Write([]byte(strings.Join(labels, "\t") + "\n")); err != nil {
 		return fmt.Errorf("failed to write to output file: %w", err)
 	}
 
-	// Process each label using the in-memory data
+	if err := writeRowsInMemoryParallel(crcW, labels, data, threads); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finalize gzip stream: %w", err)
+	}
+	return writeFooterMember(file, len(labels), len(labels), crcW.crc)
+}
+
+// rowBufPool reuses the byte buffers rendered by writeRowsInMemoryParallel's
+// workers to avoid a per-row allocation.
+var rowBufPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// renderRowInMemory formats label1's row using a pooled buffer and returns a
+// standalone copy of the bytes, safe to hand off after the buffer is
+// returned to the pool.
+func renderRowInMemory(label1 string, labels []string, data map[string]map[string]float64) []byte {
+	buf := rowBufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+
+	for i, label2 := range labels {
+		if i > 0 {
+			buf.WriteByte('\t')
+		}
+		if label1 == label2 {
+			buf.WriteString("0.0")
+		} else if dist, found := data[label1][label2]; found {
+			buf.WriteString(strconv.FormatFloat(dist, 'f', 1, 64))
+		} else {
+			buf.WriteString("1.0") // Default distance
+		}
+	}
+	buf.WriteByte('\n')
+
+	out := append([]byte(nil), buf.Bytes()...)
+	rowBufPool.Put(buf)
+	return out
+}
+
+// writeRowsInMemoryParallel renders each label's row on a worker pool bounded
+// to threads goroutines, then writes the rendered rows to w in label order.
+func writeRowsInMemoryParallel(w io.Writer, labels []string, data map[string]map[string]float64, threads int) error {
+	results := make([]chan []byte, len(labels))
+	for i := range results {
+		results[i] = make(chan []byte, 1)
+	}
+
+	sem := make(chan struct{}, threads)
+	for i, label1 := range labels {
+		sem <- struct{}{}
+		go func(i int, label1 string) {
+			defer func() { <-sem }()
+			results[i] <- renderRowInMemory(label1, labels, data)
+		}(i, label1)
+	}
+
+	for _, res := range results {
+		if _, err := w.Write(<-res); err != nil {
+			return fmt.Errorf("failed to write row: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeRecordIOMatrix writes the matrix in the chunked, indexed "recordio"
+// format (see the matrixio package) instead of the default gzip TSV, so
+// downstream tools can read arbitrary rows or label ranges without
+// inflating the whole matrix. It buffers the whole input in memory, so it is
+// only used below LargeNThreshold; writeRecordIOMatrixLarge handles -format
+// recordio above that.
+func writeRecordIOMatrix(fsys vfs.Fs, outputPath, inputPath string, labels []string, compressLevel int) error {
+	data, err := readInputFileIntoMemory(fsys, inputPath)
+	if err != nil {
+		return err
+	}
+
+	file, err := fsys.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+
+	w, err := matrixio.NewWriter(file, labels, compressLevel, matrixio.DefaultChunkRows)
+	if err != nil {
+		return fmt.Errorf("failed to create recordio writer: %w", err)
+	}
+
 	for _, label1 := range labels {
-		if err := writeRowInMemory(writer, label1, labels, data); err != nil {
-			return err // Error already wrapped
+		if err := w.WriteRow(label1, renderRowInMemory(label1, labels, data)); err != nil {
+			return fmt.Errorf("failed to write row for label %s: %w", label1, err)
 		}
 	}
 
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finalize recordio file: %w", err)
+	}
+	return nil
+}
+
+// writeRecordIOMatrixLarge writes the recordio format from the external-sort
+// merged stream instead of buffering the whole matrix in memory, so -format
+// recordio scales the same way the default gzip-TSV path does above
+// LargeNThreshold.
+func writeRecordIOMatrixLarge(fsys vfs.Fs, outputPath, inputPath string, labels []string, compressLevel, memLimit int) error {
+	file, err := fsys.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+
+	w, err := matrixio.NewWriter(file, labels, compressLevel, matrixio.DefaultChunkRows)
+	if err != nil {
+		return fmt.Errorf("failed to create recordio writer: %w", err)
+	}
+
+	merger, err := sortMatrixRecords(fsys, inputPath, memLimit)
+	if err != nil {
+		return fmt.Errorf("failed to external-sort distances: %w", err)
+	}
+	defer merger.Close()
+
+	rec, ok, err := merger.Next()
+	if err != nil {
+		return fmt.Errorf("error reading sorted distances: %w", err)
+	}
+
+	for _, label1 := range labels {
+		var rowVals map[string]float64
+		rowVals, rec, ok, err = consumeRow(merger, rec, ok, label1)
+		if err != nil {
+			return err
+		}
+
+		row := renderRowInMemory(label1, labels, map[string]map[string]float64{label1: rowVals})
+		if err := w.WriteRow(label1, row); err != nil {
+			return fmt.Errorf("failed to write row for label %s: %w", label1, err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finalize recordio file: %w", err)
+	}
+	return nil
+}
+
+// runCluster clusters labels by distance and writes the requested outputs:
+// a Newick tree at treePath, flat clusters cut at cutThreshold to
+// clustersPath, or both. Above LargeNThreshold labels only single linkage is
+// supported, computed via SLINK over the external-sort-merged distance
+// stream; below it, any of single/complete/average linkage runs against the
+// full in-memory distance map.
+func runCluster(fsys vfs.Fs, inputPath string, labels []string, linkageName string, memLimit int, treePath string, cutThreshold float64, clustersPath string) error {
+	if treePath == "" && clustersPath == "" {
+		return fmt.Errorf("-cluster requires -tree, -clusters, or both (nothing to write otherwise)")
+	}
+	if clustersPath != "" && cutThreshold < 0 {
+		return fmt.Errorf("-clusters requires -cut to be set to a non-negative distance threshold")
+	}
+
+	linkage, err := cluster.ParseLinkage(linkageName)
+	if err != nil {
+		return err
+	}
+	if len(labels) == 0 {
+		return fmt.Errorf("no labels found in %s", inputPath)
+	}
+
+	var root *cluster.Node
+	if len(labels) > LargeNThreshold {
+		if linkage != cluster.Single {
+			return fmt.Errorf("only single linkage is supported above the large-N threshold (%d labels); got %q", LargeNThreshold, linkageName)
+		}
+
+		merger, err := sortMatrixRecords(fsys, inputPath, memLimit)
+		if err != nil {
+			return fmt.Errorf("failed to external-sort distances: %w", err)
+		}
+		defer merger.Close()
+
+		rowAt := newDiskRowSource(merger, labels)
+		if _, err := rowAt(0); err != nil { // prime the stream; SLINK never queries row 0 itself
+			return fmt.Errorf("failed to read distances: %w", err)
+		}
+		root, err = cluster.SLINK(labels, rowAt)
+		if err != nil {
+			return fmt.Errorf("failed to cluster: %w", err)
+		}
+	} else {
+		data, err := readInputFileIntoMemory(fsys, inputPath)
+		if err != nil {
+			return err
+		}
+		distFor := func(a, b string) float64 {
+			if dist, found := data[a][b]; found {
+				return dist
+			}
+			return 1.0 // Default distance
+		}
+		root = cluster.AgglomerativeInMemory(labels, distFor, linkage)
+	}
+
+	if treePath != "" {
+		if err := writeFile(fsys, treePath, []byte(root.Newick()+"\n")); err != nil {
+			return fmt.Errorf("failed to write tree: %w", err)
+		}
+	}
+
+	if clustersPath != "" {
+		if err := writeClustersTSV(fsys, clustersPath, labels, cluster.CutTree(root, cutThreshold)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFile creates name on fsys and writes data to it in one call, mirroring
+// os.WriteFile for the vfs.Fs abstraction.
+func writeFile(fsys vfs.Fs, name string, data []byte) error {
+	file, err := fsys.Create(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(data)
+	return err
+}
+
+// newDiskRowSource returns a rowAt(i) function that consumes merger's
+// (row, col)-sorted stream one full row at a time, same as
+// writeRowsFromMerger, but returns the row's distances to labels[:i] for
+// SLINK. Every row must be read in label order -- including row 0, which
+// SLINK never queries directly -- to keep the merger's cursor in sync.
+func newDiskRowSource(merger *extsort.Merger, labels []string) func(i int) ([]float64, error) {
+	rec, ok, err := merger.Next()
+	return func(i int) ([]float64, error) {
+		if err != nil {
+			return nil, fmt.Errorf("error reading sorted distances: %w", err)
+		}
+
+		label1 := labels[i]
+		var rowVals map[string]float64
+		rowVals, rec, ok, err = consumeRow(merger, rec, ok, label1)
+		if err != nil {
+			return nil, err
+		}
+
+		row := make([]float64, i)
+		for j := 0; j < i; j++ {
+			if dist, found := rowVals[labels[j]]; found {
+				row[j] = dist
+			} else {
+				row[j] = 1.0 // Default distance
+			}
+		}
+		return row, nil
+	}
+}
+
+// writeClustersTSV writes one "label\tclusterID" line per label, in label
+// order, for the flat clusters produced by cluster.CutTree.
+func writeClustersTSV(fsys vfs.Fs, path string, labels []string, assignments map[string]int) error {
+	file, err := fsys.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create clusters file: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	defer writer.Flush()
+
+	for _, label := range labels {
+		if _, err := fmt.Fprintf(writer, "%s\t%d\n", label, assignments[label]); err != nil {
+			return fmt.Errorf("failed to write cluster assignment for %s: %w", label, err)
+		}
+	}
 	return nil
 }
 
 // Read the input file and stores distances in a nested map
-func readInputFileIntoMemory(inputPath string) (map[string]map[string]float64, error) {
+func readInputFileIntoMemory(fsys vfs.Fs, inputPath string) (map[string]map[string]float64, error) {
 	data := make(map[string]map[string]float64)
-	file, err := os.Open(inputPath)
+	file, err := fsys.Open(inputPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open input file: %w", err)
 	}
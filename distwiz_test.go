@@ -0,0 +1,80 @@
+package distwiz
+
+import (
+	"bufio"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/vmikk/distwiz/vfs"
+)
+
+// TestWriteSquareMatrixDuplicateAndSelfPairs is a regression test for a
+// desync bug in the external-sort row walk: a self-referential (a, a, d)
+// triple or a duplicate (a, b, d) pair left an unconsumed record on the
+// merged stream's cursor, which silently defaulted every remaining cell in
+// every subsequent row to 1.0 instead of erroring or recovering.
+func TestWriteSquareMatrixDuplicateAndSelfPairs(t *testing.T) {
+	labels := []string{"a", "b", "c", "d", "e"}
+	input := strings.Join([]string{
+		"a\tb\t0.1",
+		"a\tc\t0.2",
+		"a\ta\t0.9", // self-referential; should not appear in the output
+		"b\tc\t0.3",
+		"b\tc\t0.3", // duplicate pair
+		"c\td\t0.4",
+		"d\te\t0.5",
+	}, "\n") + "\n"
+
+	fsys := vfs.NewMem()
+	if err := writeFile(fsys, "input.tsv", []byte(input)); err != nil {
+		t.Fatalf("failed to seed input: %v", err)
+	}
+
+	if err := writeSquareMatrix(fsys, "out.tsv.gz", "input.tsv", labels, 4, 1<<20); err != nil {
+		t.Fatalf("writeSquareMatrix: %v", err)
+	}
+
+	f, err := fsys.Open("out.tsv.gz")
+	if err != nil {
+		t.Fatalf("failed to open output: %v", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("failed to open gzip stream: %v", err)
+	}
+	defer gz.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+
+	want := []string{
+		"a\tb\tc\td\te",
+		"0.0\t0.1\t0.2\t1.0\t1.0",
+		"0.1\t0.0\t0.3\t1.0\t1.0",
+		"0.2\t0.3\t0.0\t0.4\t1.0",
+		"1.0\t1.0\t0.4\t0.0\t0.5",
+		"1.0\t1.0\t1.0\t0.5\t0.0",
+	}
+	// The last line is the integrity footer from writeFooterMember; ignore it.
+	if len(lines) > 0 && strings.HasPrefix(lines[len(lines)-1], FooterMagic) {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: got=%v", len(lines), len(want), lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("row %d: got %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
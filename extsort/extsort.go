@@ -0,0 +1,211 @@
+// Package extsort implements external (on-disk) merge sorting for streams of
+// records that do not fit comfortably in memory. Records are buffered up to
+// a configurable byte budget, sorted in memory and flushed to a temporary run
+// file; once the input is exhausted the runs are merged with a k-way merge
+// so the combined output is produced by a single linear scan, without ever
+// holding more than one run's worth of records in memory. Run files are
+// created through a vfs.Fs, so a Sorter backed by vfs.Mem never touches real
+// disk.
+package extsort
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+
+	"github.com/vmikk/distwiz/vfs"
+)
+
+// tempSeq generates unique run file names across all Sorters in the
+// process, since vfs.Fs has no equivalent of os.CreateTemp's collision-free
+// name generation. It's combined with the process ID so two distwiz
+// processes sharing a working directory (and so each starting tempSeq back
+// at 0) still can't collide.
+var tempSeq int64
+
+func tempRunName(dir string) string {
+	name := fmt.Sprintf("extsort-run-%d-%d", os.Getpid(), atomic.AddInt64(&tempSeq, 1))
+	if dir == "" {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+// Record is a single value being sorted. Callers supply a Less function and
+// a Codec to give extsort meaning for whatever concrete type they use.
+type Record interface{}
+
+// Less reports whether a sorts before b.
+type Less func(a, b Record) bool
+
+// Codec encodes and decodes Records to and from a run file. Size estimates
+// the in-memory footprint of rec so the Sorter can bound run sizes without
+// re-encoding every record just to measure it. Decode must return io.EOF
+// (wrapped or bare) when r is exhausted.
+type Codec interface {
+	Encode(w *bufio.Writer, rec Record) error
+	Decode(r *bufio.Reader) (Record, error)
+	Size(rec Record) int
+}
+
+// Sorter buffers records in memory up to MemLimit bytes, spills sorted runs
+// to run files created through Fs (prefixed with TempDir, if set), and
+// produces a Merger over those runs.
+type Sorter struct {
+	MemLimit int
+	TempDir  string
+	Fs       vfs.Fs
+	Less     Less
+	Codec    Codec
+}
+
+// New returns a Sorter that flushes a run once its buffered records reach
+// approximately memLimit bytes (as estimated by codec.Size). Run files are
+// created through fsys, so a Sorter backed by vfs.Mem never touches disk;
+// fsys defaults to vfs.OS{} if nil.
+func New(fsys vfs.Fs, memLimit int, less Less, codec Codec) *Sorter {
+	if fsys == nil {
+		fsys = vfs.OS{}
+	}
+	return &Sorter{MemLimit: memLimit, Fs: fsys, Less: less, Codec: codec}
+}
+
+// Sort drains records, writing sorted runs to run files, and returns a
+// Merger that yields the fully merged, sorted stream. The caller must Close
+// the returned Merger to remove the run files.
+func (s *Sorter) Sort(records <-chan Record) (*Merger, error) {
+	var runFiles []vfs.File
+	var buf []Record
+	size := 0
+
+	flush := func() error {
+		if len(buf) == 0 {
+			return nil
+		}
+		sort.Slice(buf, func(i, j int) bool { return s.Less(buf[i], buf[j]) })
+
+		f, err := s.Fs.Create(tempRunName(s.TempDir))
+		if err != nil {
+			return err
+		}
+		w := bufio.NewWriter(f)
+		for _, rec := range buf {
+			if err := s.Codec.Encode(w, rec); err != nil {
+				return err
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		runFiles = append(runFiles, f)
+		buf = buf[:0]
+		size = 0
+		return nil
+	}
+
+	for rec := range records {
+		buf = append(buf, rec)
+		size += s.Codec.Size(rec)
+		if size >= s.MemLimit {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return newMerger(s.Fs, runFiles, s.Less, s.Codec)
+}
+
+// mergeItem is one run's current head record, kept alongside the reader it
+// came from so Next can pull the run's following record once this one pops.
+type mergeItem struct {
+	rec Record
+	r   *bufio.Reader
+}
+
+type recordHeap struct {
+	items []mergeItem
+	less  Less
+}
+
+func (h *recordHeap) Len() int           { return len(h.items) }
+func (h *recordHeap) Less(i, j int) bool { return h.less(h.items[i].rec, h.items[j].rec) }
+func (h *recordHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *recordHeap) Push(x interface{}) { h.items = append(h.items, x.(mergeItem)) }
+func (h *recordHeap) Pop() interface{} {
+	old := h.items
+	n := len(old)
+	item := old[n-1]
+	h.items = old[:n-1]
+	return item
+}
+
+// Merger performs a k-way merge over the sorted run files produced by
+// Sorter.Sort, yielding records one at a time in global sorted order.
+type Merger struct {
+	fs    vfs.Fs
+	files []vfs.File
+	codec Codec
+	heap  *recordHeap
+}
+
+func newMerger(fs vfs.Fs, files []vfs.File, less Less, codec Codec) (*Merger, error) {
+	h := &recordHeap{less: less}
+	for _, f := range files {
+		r := bufio.NewReader(f)
+		rec, err := codec.Decode(r)
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		h.items = append(h.items, mergeItem{rec: rec, r: r})
+	}
+	heap.Init(h)
+	return &Merger{fs: fs, files: files, codec: codec, heap: h}, nil
+}
+
+// Next returns the next record in sorted order, or ok == false once every
+// run has been fully consumed.
+func (m *Merger) Next() (Record, bool, error) {
+	if m.heap.Len() == 0 {
+		return nil, false, nil
+	}
+	item := heap.Pop(m.heap).(mergeItem)
+
+	next, err := m.codec.Decode(item.r)
+	if err == nil {
+		heap.Push(m.heap, mergeItem{rec: next, r: item.r})
+	} else if err != io.EOF {
+		return nil, false, err
+	}
+
+	return item.rec, true, nil
+}
+
+// Close closes and removes every run file backing the merge. It is safe to
+// call even if Next has not been drained to completion.
+func (m *Merger) Close() error {
+	var firstErr error
+	for _, f := range m.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := m.fs.Remove(f.Name()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,148 @@
+package distwiz
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/vmikk/distwiz/vfs"
+)
+
+// FooterMagic prefixes the integrity footer line so it reads as a comment
+// to any consumer that doesn't know about it.
+const FooterMagic = "#distwiz-footer"
+
+// FooterVersion allows the footer line's fields to evolve.
+const FooterVersion = 1
+
+// castagnoliTable is used for the footer CRC, matching the CRC32C used
+// elsewhere for integrity checks (e.g. etcd's WAL).
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// crcWriter wraps an io.Writer, accumulating a running CRC32C of every byte
+// written through it without altering what's written.
+type crcWriter struct {
+	w   io.Writer
+	crc uint32
+}
+
+func newCRCWriter(w io.Writer) *crcWriter {
+	return &crcWriter{w: w}
+}
+
+func (c *crcWriter) Write(p []byte) (int, error) {
+	c.crc = crc32.Update(c.crc, castagnoliTable, p)
+	return c.w.Write(p)
+}
+
+// writeFooterMember appends a final, independent gzip member holding a
+// single footer line: magic, version, row/column counts and the CRC32C of
+// the uncompressed TSV body that preceded it. Because gzip streams are a
+// concatenation of members, `zcat` still yields the TSV followed by one
+// comment-prefixed line, so existing consumers keep working unmodified.
+func writeFooterMember(w io.Writer, rows, cols int, crc uint32) error {
+	gz, err := gzip.NewWriterLevel(w, gzip.DefaultCompression)
+	if err != nil {
+		return fmt.Errorf("failed to create footer gzip writer: %w", err)
+	}
+	line := fmt.Sprintf("%s version=%d rows=%d cols=%d crc32c=%08x\n", FooterMagic, FooterVersion, rows, cols, crc)
+	if _, err := io.WriteString(gz, line); err != nil {
+		return fmt.Errorf("failed to write footer: %w", err)
+	}
+	return gz.Close()
+}
+
+// parseFooterLine parses a line written by writeFooterMember.
+func parseFooterLine(line string) (rows, cols int, crc uint32, err error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != FooterMagic {
+		return 0, 0, 0, fmt.Errorf("malformed footer line: %q", line)
+	}
+
+	kv := make(map[string]string, len(fields)-1)
+	for _, f := range fields[1:] {
+		if k, v, ok := strings.Cut(f, "="); ok {
+			kv[k] = v
+		}
+	}
+
+	rows, err = strconv.Atoi(kv["rows"])
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("bad rows field in footer: %w", err)
+	}
+	cols, err = strconv.Atoi(kv["cols"])
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("bad cols field in footer: %w", err)
+	}
+	crc64, err := strconv.ParseUint(kv["crc32c"], 16, 32)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("bad crc32c field in footer: %w", err)
+	}
+	return rows, cols, uint32(crc64), nil
+}
+
+// Verify streams a gzip TSV file written by Run, recomputes the CRC32C of
+// its TSV body and the row/column counts, and compares them against the
+// trailing integrity footer written by writeFooterMember. It returns an
+// error describing the mismatch or truncation, or nil if the file verifies.
+func Verify(fsys vfs.Fs, path string) error {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to open gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	crcW := newCRCWriter(io.Discard)
+	var footerLine string
+	var headerLine string
+	rows := -1 // header line doesn't count as a row
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<24)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, FooterMagic) {
+			footerLine = line
+			continue
+		}
+		if rows == -1 {
+			headerLine = line
+		}
+		if _, err := crcW.Write([]byte(line + "\n")); err != nil {
+			return fmt.Errorf("error recomputing CRC: %w", err)
+		}
+		rows++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	if footerLine == "" {
+		return fmt.Errorf("%s: truncated or missing integrity footer", path)
+	}
+
+	wantRows, wantCols, wantCRC, err := parseFooterLine(footerLine)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if rows != wantRows {
+		return fmt.Errorf("%s: row count mismatch: footer says %d, found %d", path, wantRows, rows)
+	}
+	if gotCols := len(strings.Split(headerLine, "\t")); gotCols != wantCols {
+		return fmt.Errorf("%s: column count mismatch: footer says %d, found %d", path, wantCols, gotCols)
+	}
+	if crcW.crc != wantCRC {
+		return fmt.Errorf("%s: CRC32C mismatch: footer says %08x, computed %08x", path, wantCRC, crcW.crc)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package distwiz
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/vmikk/distwiz/vfs"
+)
+
+// buildValidOutput writes a small square matrix via writeSquareMatrixInMemory
+// (so the test exercises the same footer-writing path Run does) and returns
+// the path it was written to.
+func buildValidOutput(t *testing.T, fsys vfs.Fs) string {
+	t.Helper()
+	labels := []string{"a", "b", "c"}
+	input := "a\tb\t0.1\nb\tc\t0.2\na\tc\t0.3\n"
+	if err := writeFile(fsys, "input.tsv", []byte(input)); err != nil {
+		t.Fatalf("failed to seed input: %v", err)
+	}
+	if err := writeSquareMatrixInMemory(fsys, "out.tsv.gz", "input.tsv", labels, 4, 1); err != nil {
+		t.Fatalf("writeSquareMatrixInMemory: %v", err)
+	}
+	return "out.tsv.gz"
+}
+
+func TestVerifyHappyPath(t *testing.T) {
+	fsys := vfs.NewMem()
+	path := buildValidOutput(t, fsys)
+
+	if err := Verify(fsys, path); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
+
+// TestVerifyDetectsCRCMismatch builds a file with writeFooterMember itself,
+// the same helper Run uses, but deliberately passes it a CRC that doesn't
+// match the body -- simulating bytes that got corrupted in transit after
+// the footer was written but before the file reached Verify.
+func TestVerifyDetectsCRCMismatch(t *testing.T) {
+	fsys := vfs.NewMem()
+	labels := []string{"a", "b", "c"}
+
+	file, err := fsys.Create("bad.tsv.gz")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	gz := gzip.NewWriter(file)
+	crcW := newCRCWriter(gz)
+	bw := bufio.NewWriter(crcW)
+	for _, line := range []string{
+		"a\tb\tc",
+		"a\t0\t0.1\t0.3",
+		"b\t0.1\t0\t0.2",
+		"c\t0.3\t0.2\t0",
+	} {
+		if _, err := bw.WriteString(line + "\n"); err != nil {
+			t.Fatalf("WriteString: %v", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip Close: %v", err)
+	}
+	if err := writeFooterMember(file, len(labels), len(labels), crcW.crc+1); err != nil {
+		t.Fatalf("writeFooterMember: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	err = Verify(fsys, "bad.tsv.gz")
+	if err == nil {
+		t.Fatal("expected a CRC mismatch error")
+	}
+	if !strings.Contains(err.Error(), "CRC32C mismatch") {
+		t.Fatalf("got error %q, want a CRC32C mismatch error", err)
+	}
+}
+
+func TestVerifyDetectsTruncation(t *testing.T) {
+	fsys := vfs.NewMem()
+	path := buildValidOutput(t, fsys)
+
+	f, err := fsys.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	body, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("readAll: %v", err)
+	}
+
+	// Cut off the trailing footer member entirely, simulating a write that
+	// was interrupted before the footer was flushed.
+	truncated := body[:len(body)/2]
+	if err := writeFile(fsys, "truncated.tsv.gz", truncated); err != nil {
+		t.Fatalf("failed to write truncated file: %v", err)
+	}
+
+	if err := Verify(fsys, "truncated.tsv.gz"); err == nil {
+		t.Fatal("expected an error verifying a truncated file")
+	}
+}
+
+func TestVerifyMissingFile(t *testing.T) {
+	fsys := vfs.NewMem()
+	if err := Verify(fsys, "does-not-exist.tsv.gz"); err == nil {
+		t.Fatal("expected an error verifying a file that doesn't exist")
+	}
+}
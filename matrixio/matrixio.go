@@ -0,0 +1,290 @@
+// Package matrixio implements a chunked, indexed binary matrix format
+// ("recordio"): the file is a sequence of independently gzip-compressed
+// chunks, each holding a contiguous run of matrix rows, followed by a
+// footer holding a chunk index (offset, length, first-row-label,
+// row-count) and a magic/version header. Unlike the default gzip-TSV
+// output, a recordio file lets a reader pull arbitrary rows or label
+// ranges without inflating the whole matrix, which suits clustering code
+// that consumes distances row-by-row.
+package matrixio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/vmikk/distwiz/vfs"
+)
+
+// Magic identifies a recordio file; Version allows the layout to evolve.
+const (
+	Magic        = "DWMXRIO1"
+	FooterMagic  = "DWMXFTR1"
+	Version      = 1
+	footerTailSz = len(FooterMagic) + 8 // footer length (uint64) + magic
+)
+
+// DefaultChunkRows is the number of rows grouped into one compressed chunk
+// when a caller doesn't need a different size.
+const DefaultChunkRows = 1024
+
+// ChunkMeta describes one compressed chunk in the file.
+type ChunkMeta struct {
+	Offset        int64
+	Length        int64
+	FirstRowLabel string
+	RowCount      int
+}
+
+// Index is the footer: the full row/column label list (in row order) plus
+// the chunk table needed to map a label to the chunk holding its row.
+type Index struct {
+	Labels []string
+	Chunks []ChunkMeta
+}
+
+// LoadIndex reads and decodes the footer from a recordio file.
+func LoadIndex(f vfs.File) (*Index, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to size recordio file: %w", err)
+	}
+	if size < int64(len(Magic)+footerTailSz) {
+		return nil, fmt.Errorf("file too small to be a recordio file")
+	}
+
+	tail := make([]byte, footerTailSz)
+	if _, err := f.ReadAt(tail, size-int64(footerTailSz)); err != nil {
+		return nil, fmt.Errorf("failed to read recordio footer tail: %w", err)
+	}
+	if string(tail[8:]) != FooterMagic {
+		return nil, fmt.Errorf("not a recordio file: bad footer magic")
+	}
+	footerLen := binary.LittleEndian.Uint64(tail[:8])
+
+	footerStart := size - int64(footerTailSz) - int64(footerLen)
+	if footerStart < int64(len(Magic)) {
+		return nil, fmt.Errorf("recordio footer length out of range")
+	}
+	footerBuf := make([]byte, footerLen)
+	if _, err := f.ReadAt(footerBuf, footerStart); err != nil {
+		return nil, fmt.Errorf("failed to read recordio footer: %w", err)
+	}
+
+	var idx Index
+	if err := gob.NewDecoder(bytes.NewReader(footerBuf)).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("failed to decode recordio footer: %w", err)
+	}
+	return &idx, nil
+}
+
+// readChunkLines reads and gzip-decompresses chunk c and splits it into its
+// constituent row lines.
+func readChunkLines(f vfs.File, c ChunkMeta) ([]string, error) {
+	raw := make([]byte, c.Length)
+	if _, err := f.ReadAt(raw, c.Offset); err != nil {
+		return nil, fmt.Errorf("failed to read chunk at offset %d: %w", c.Offset, err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chunk gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	body, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress chunk: %w", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	if len(lines) != c.RowCount {
+		return nil, fmt.Errorf("chunk at offset %d: expected %d rows, got %d", c.Offset, c.RowCount, len(lines))
+	}
+	return lines, nil
+}
+
+// ReadRow decompresses only the chunk that holds label's row and returns
+// its tab-separated values.
+func ReadRow(f vfs.File, idx *Index, label string) ([]string, error) {
+	pos := sort.SearchStrings(idx.Labels, label)
+	if pos == len(idx.Labels) || idx.Labels[pos] != label {
+		return nil, fmt.Errorf("label %q not found in index", label)
+	}
+
+	cum := 0
+	for _, c := range idx.Chunks {
+		if pos < cum+c.RowCount {
+			lines, err := readChunkLines(f, c)
+			if err != nil {
+				return nil, err
+			}
+			return strings.Split(lines[pos-cum], "\t"), nil
+		}
+		cum += c.RowCount
+	}
+	return nil, fmt.Errorf("label %q out of range of the chunk index", label)
+}
+
+// RowScanner walks a contiguous range of rows [start, end) in label order,
+// decompressing each chunk at most once as the scan reaches it.
+type RowScanner struct {
+	f        vfs.File
+	idx      *Index
+	pos, end int
+
+	curChunk int
+	curLines []string
+}
+
+// NewRowScanner returns a scanner over rows [start, end) of idx's label
+// order (end exclusive). Use LoadIndex's Index and len(idx.Labels) for the
+// full range.
+func NewRowScanner(f vfs.File, idx *Index, start, end int) (*RowScanner, error) {
+	if start < 0 || end > len(idx.Labels) || start > end {
+		return nil, fmt.Errorf("invalid row range [%d, %d) for %d labels", start, end, len(idx.Labels))
+	}
+	return &RowScanner{f: f, idx: idx, pos: start, end: end, curChunk: -1}, nil
+}
+
+// Next returns the next row's label and tab-separated values, or
+// ok == false once the scanner's range is exhausted.
+func (s *RowScanner) Next() (label string, row []string, ok bool, err error) {
+	if s.pos >= s.end {
+		return "", nil, false, nil
+	}
+
+	cum := 0
+	for ci, c := range s.idx.Chunks {
+		if s.pos < cum+c.RowCount {
+			if s.curChunk != ci {
+				lines, err := readChunkLines(s.f, c)
+				if err != nil {
+					return "", nil, false, err
+				}
+				s.curLines = lines
+				s.curChunk = ci
+			}
+			label = s.idx.Labels[s.pos]
+			row = strings.Split(s.curLines[s.pos-cum], "\t")
+			s.pos++
+			return label, row, true, nil
+		}
+		cum += c.RowCount
+	}
+	return "", nil, false, fmt.Errorf("row %d out of range of the chunk index", s.pos)
+}
+
+// Writer assembles rows into gzip-compressed chunks and writes the
+// recordio container format: a magic header, the chunks themselves, and a
+// trailing footer with the chunk index.
+type Writer struct {
+	w             io.Writer
+	compressLevel int
+	chunkRows     int
+
+	labels []string
+	chunks []ChunkMeta
+	offset int64
+
+	pending      bytes.Buffer
+	pendingRows  int
+	pendingFirst string
+}
+
+// NewWriter writes the recordio magic header to w and returns a Writer that
+// groups rows into chunks of chunkRows rows each (DefaultChunkRows if <= 0).
+func NewWriter(w io.Writer, labels []string, compressLevel, chunkRows int) (*Writer, error) {
+	if chunkRows <= 0 {
+		chunkRows = DefaultChunkRows
+	}
+	if _, err := io.WriteString(w, Magic); err != nil {
+		return nil, fmt.Errorf("failed to write recordio magic header: %w", err)
+	}
+	return &Writer{
+		w:             w,
+		compressLevel: compressLevel,
+		chunkRows:     chunkRows,
+		labels:        labels,
+		offset:        int64(len(Magic)),
+	}, nil
+}
+
+// WriteRow appends one row (already tab-separated, newline-terminated) for
+// label, flushing a compressed chunk once chunkRows rows have accumulated.
+func (w *Writer) WriteRow(label string, row []byte) error {
+	if w.pendingRows == 0 {
+		w.pendingFirst = label
+	}
+	w.pending.Write(row)
+	w.pendingRows++
+
+	if w.pendingRows >= w.chunkRows {
+		return w.flushChunk()
+	}
+	return nil
+}
+
+func (w *Writer) flushChunk() error {
+	if w.pendingRows == 0 {
+		return nil
+	}
+
+	var compressed bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&compressed, w.compressLevel)
+	if err != nil {
+		return fmt.Errorf("failed to create chunk gzip writer: %w", err)
+	}
+	if _, err := gz.Write(w.pending.Bytes()); err != nil {
+		return fmt.Errorf("failed to compress chunk: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finalize chunk gzip stream: %w", err)
+	}
+
+	n, err := w.w.Write(compressed.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to write chunk: %w", err)
+	}
+
+	w.chunks = append(w.chunks, ChunkMeta{
+		Offset:        w.offset,
+		Length:        int64(n),
+		FirstRowLabel: w.pendingFirst,
+		RowCount:      w.pendingRows,
+	})
+	w.offset += int64(n)
+	w.pending.Reset()
+	w.pendingRows = 0
+	return nil
+}
+
+// Close flushes any partial chunk and writes the footer (chunk index and
+// magic trailer). It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.flushChunk(); err != nil {
+		return err
+	}
+
+	var footerBuf bytes.Buffer
+	idx := Index{Labels: w.labels, Chunks: w.chunks}
+	if err := gob.NewEncoder(&footerBuf).Encode(idx); err != nil {
+		return fmt.Errorf("failed to encode recordio footer: %w", err)
+	}
+	if _, err := w.w.Write(footerBuf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write recordio footer: %w", err)
+	}
+
+	var tail [8]byte
+	binary.LittleEndian.PutUint64(tail[:], uint64(footerBuf.Len()))
+	if _, err := w.w.Write(tail[:]); err != nil {
+		return fmt.Errorf("failed to write recordio footer length: %w", err)
+	}
+	if _, err := io.WriteString(w.w, FooterMagic); err != nil {
+		return fmt.Errorf("failed to write recordio footer magic: %w", err)
+	}
+	return nil
+}
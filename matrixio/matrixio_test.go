@@ -0,0 +1,136 @@
+package matrixio
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vmikk/distwiz/vfs"
+)
+
+// writeFixture writes a recordio file with the given chunkRows (small, to
+// force multiple chunks) and returns its labels and the vfs.File it was
+// written to, seeked back to the start.
+func writeFixture(t *testing.T, chunkRows int) ([]string, vfs.File) {
+	t.Helper()
+	labels := []string{"a", "b", "c", "d", "e", "f", "g"}
+
+	fs := vfs.NewMem()
+	f, err := fs.Create("matrix.rio")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	w, err := NewWriter(f, labels, 4, chunkRows)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	for _, label := range labels {
+		row := []byte(strings.Repeat(label+"\t", len(labels)-1) + label + "\n")
+		if err := w.WriteRow(label, row); err != nil {
+			t.Fatalf("WriteRow(%q): %v", label, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	return labels, f
+}
+
+func TestLoadIndexRoundTrip(t *testing.T) {
+	labels, f := writeFixture(t, 3) // 7 rows / 3 per chunk -> 3 chunks, last partial
+
+	idx, err := LoadIndex(f)
+	if err != nil {
+		t.Fatalf("LoadIndex: %v", err)
+	}
+	if len(idx.Labels) != len(labels) {
+		t.Fatalf("got %d labels, want %d", len(idx.Labels), len(labels))
+	}
+	for i, l := range labels {
+		if idx.Labels[i] != l {
+			t.Errorf("label %d: got %q, want %q", i, idx.Labels[i], l)
+		}
+	}
+	if len(idx.Chunks) != 3 {
+		t.Fatalf("got %d chunks, want 3", len(idx.Chunks))
+	}
+	if idx.Chunks[0].RowCount != 3 || idx.Chunks[1].RowCount != 3 || idx.Chunks[2].RowCount != 1 {
+		t.Errorf("unexpected chunk row counts: %+v", idx.Chunks)
+	}
+}
+
+func TestReadRowAcrossChunks(t *testing.T) {
+	labels, f := writeFixture(t, 3)
+
+	idx, err := LoadIndex(f)
+	if err != nil {
+		t.Fatalf("LoadIndex: %v", err)
+	}
+
+	// "d" is the first row of the second chunk, and "e" spans into it too --
+	// exercise a row from each chunk, including the chunk boundary itself.
+	for _, label := range []string{"a", "c", "d", "e", "g"} {
+		row, err := ReadRow(f, idx, label)
+		if err != nil {
+			t.Fatalf("ReadRow(%q): %v", label, err)
+		}
+		if len(row) != len(labels) {
+			t.Fatalf("ReadRow(%q): got %d columns, want %d", label, len(row), len(labels))
+		}
+		for _, v := range row {
+			if v != label {
+				t.Errorf("ReadRow(%q): got column value %q, want %q", label, v, label)
+			}
+		}
+	}
+
+	if _, err := ReadRow(f, idx, "missing"); err == nil {
+		t.Fatal("expected an error reading a label not in the index")
+	}
+}
+
+func TestRowScannerSpansChunkBoundary(t *testing.T) {
+	labels, f := writeFixture(t, 3)
+
+	idx, err := LoadIndex(f)
+	if err != nil {
+		t.Fatalf("LoadIndex: %v", err)
+	}
+
+	// Rows [2, 5) straddle the boundary between chunk 0 (rows 0-2) and
+	// chunk 1 (rows 3-5): "c" is the last row of chunk 0, "d" and "e" are
+	// the first two rows of chunk 1.
+	scanner, err := NewRowScanner(f, idx, 2, 5)
+	if err != nil {
+		t.Fatalf("NewRowScanner: %v", err)
+	}
+
+	var got []string
+	for {
+		label, row, ok, err := scanner.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if !ok {
+			break
+		}
+		if len(row) != len(labels) || row[0] != label {
+			t.Errorf("Next() row for %q looks wrong: %v", label, row)
+		}
+		got = append(got, label)
+	}
+
+	want := []string{"c", "d", "e"}
+	if len(got) != len(want) {
+		t.Fatalf("got labels %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("label %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
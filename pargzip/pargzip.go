@@ -0,0 +1,163 @@
+// Package pargzip implements a block-parallel gzip writer. Input is split
+// into fixed-size blocks, each compressed as its own gzip member on a
+// separate goroutine, and the members are written to the underlying writer
+// in order as each one finishes compressing -- not buffered up and flushed
+// all at once on Close. Since a gzip stream is simply a concatenation of
+// independent members, the result is a single file that stock gzip/zcat
+// reads like any other gzip stream.
+package pargzip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+// DefaultBlockSize is the block size used when NewWriter is given one <= 0.
+const DefaultBlockSize = 1 << 20 // 1 MiB
+
+// Writer is an io.WriteCloser. It must be Close'd to flush the final block
+// and wait for every pending member to reach the underlying writer.
+type Writer struct {
+	w         io.Writer
+	level     int
+	blockSize int
+
+	buf bytes.Buffer
+	sem chan struct{}
+
+	futures   chan chan blockResult
+	drainDone chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+type blockResult struct {
+	data []byte
+	err  error
+}
+
+// NewWriter returns a Writer that compresses blockSize-byte blocks at the
+// given gzip level, running up to threads compressions concurrently.
+// threads < 1 is treated as 1.
+func NewWriter(w io.Writer, level, blockSize, threads int) *Writer {
+	if blockSize <= 0 {
+		blockSize = DefaultBlockSize
+	}
+	if threads < 1 {
+		threads = 1
+	}
+	pw := &Writer{
+		w:         w,
+		level:     level,
+		blockSize: blockSize,
+		sem:       make(chan struct{}, threads),
+		futures:   make(chan chan blockResult),
+		drainDone: make(chan struct{}),
+	}
+	go pw.drain()
+	return pw
+}
+
+// drain writes each dispatched block's compressed member to the underlying
+// writer, in dispatch order, as soon as that block's compression completes --
+// it does not wait for the stream to close. It keeps consuming futures after
+// an error so dispatchBlock's producers are never left blocked on a full
+// channel, but stops writing to w once the first error is recorded.
+func (pw *Writer) drain() {
+	defer close(pw.drainDone)
+	for fut := range pw.futures {
+		res := <-fut
+		if res.err != nil {
+			pw.setErr(res.err)
+			continue
+		}
+		if pw.getErr() != nil {
+			continue
+		}
+		if _, err := pw.w.Write(res.data); err != nil {
+			pw.setErr(err)
+		}
+	}
+}
+
+func (pw *Writer) setErr(err error) {
+	pw.mu.Lock()
+	if pw.err == nil {
+		pw.err = err
+	}
+	pw.mu.Unlock()
+}
+
+func (pw *Writer) getErr() error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	return pw.err
+}
+
+// Write buffers p, compressing and dispatching full blocks as they fill.
+func (pw *Writer) Write(p []byte) (int, error) {
+	if err := pw.getErr(); err != nil {
+		return 0, err
+	}
+
+	total := len(p)
+	for len(p) > 0 {
+		room := pw.blockSize - pw.buf.Len()
+		if room > len(p) {
+			pw.buf.Write(p)
+			break
+		}
+		pw.buf.Write(p[:room])
+		p = p[room:]
+		pw.dispatchBlock()
+	}
+	return total, nil
+}
+
+// dispatchBlock hands the current buffer contents to a compressor goroutine
+// and resets the buffer for the next block. The goroutine count in flight is
+// bounded by the Writer's semaphore, so this call blocks once that many
+// compressions are already running.
+func (pw *Writer) dispatchBlock() {
+	block := append([]byte(nil), pw.buf.Bytes()...)
+	pw.buf.Reset()
+
+	fut := make(chan blockResult, 1)
+
+	pw.sem <- struct{}{}
+	go func() {
+		defer func() { <-pw.sem }()
+		var out bytes.Buffer
+		gz, err := gzip.NewWriterLevel(&out, pw.level)
+		if err != nil {
+			fut <- blockResult{err: err}
+			return
+		}
+		if _, err := gz.Write(block); err != nil {
+			fut <- blockResult{err: err}
+			return
+		}
+		if err := gz.Close(); err != nil {
+			fut <- blockResult{err: err}
+			return
+		}
+		fut <- blockResult{data: out.Bytes()}
+	}()
+
+	pw.futures <- fut
+}
+
+// Close flushes any remaining buffered bytes as a final block, then waits
+// for the drain goroutine to finish writing every block's compressed member
+// to the underlying writer, in order.
+func (pw *Writer) Close() error {
+	if pw.buf.Len() > 0 {
+		pw.dispatchBlock()
+	}
+	close(pw.futures)
+	<-pw.drainDone
+	return pw.getErr()
+}
@@ -0,0 +1,83 @@
+package vfs
+
+import (
+	"io"
+	"testing"
+)
+
+func TestMemCreateThenOpenSeesWrites(t *testing.T) {
+	fs := NewMem()
+
+	w, err := fs.Create("a.txt")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := w.Write([]byte("hello world")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	r, err := fs.Open("a.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(got) != "hello world" {
+		t.Fatalf("got %q, want %q", got, "hello world")
+	}
+}
+
+func TestMemOpenMissingFails(t *testing.T) {
+	fs := NewMem()
+	if _, err := fs.Open("missing.txt"); err == nil {
+		t.Fatal("expected an error opening a file that was never created")
+	}
+}
+
+func TestMemSeekAndReadAt(t *testing.T) {
+	fs := NewMem()
+	f, err := fs.Create("b.txt")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := f.Write([]byte("0123456789")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	buf := make([]byte, 4)
+	if _, err := f.ReadAt(buf, 3); err != nil {
+		t.Fatalf("ReadAt: %v", err)
+	}
+	if string(buf) != "3456" {
+		t.Fatalf("ReadAt got %q, want %q", buf, "3456")
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	all, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("ReadAll after Seek: %v", err)
+	}
+	if string(all) != "0123456789" {
+		t.Fatalf("got %q after seeking to start, want %q", all, "0123456789")
+	}
+}
+
+func TestMemRemove(t *testing.T) {
+	fs := NewMem()
+	if _, err := fs.Create("c.txt"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := fs.Remove("c.txt"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := fs.Open("c.txt"); err == nil {
+		t.Fatal("expected an error opening a file removed earlier")
+	}
+	if err := fs.Remove("c.txt"); err == nil {
+		t.Fatal("expected an error removing a file twice")
+	}
+}